@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws/session"
+
+	l "github.com/ragurney/travis-job/internal/lib"
+	t "github.com/ragurney/travis-job/pkg/travis"
+)
+
+// handleCodePipelineJob runs one invocation of the Travis job behind a
+// CodePipeline custom action. If the invoking job carries a continuation
+// token (set by a previous invocation's ReportInProgress), it resumes
+// checking that Travis request instead of triggering a new build. Execute
+// reports the result back to CodePipeline via CodePipelineReporter and
+// returns without blocking for the life of the build, relying on
+// CodePipeline to invoke this handler again once the next check is due.
+func handleCodePipelineJob(ctx context.Context, event events.CodePipelineEvent) error {
+	job := event.CodePipelineJob
+
+	requestID := ""
+	if job.Data.ContinuationToken != "" {
+		var token t.ContinuationToken
+		if err := json.Unmarshal([]byte(job.Data.ContinuationToken), &token); err != nil {
+			return err
+		}
+		requestID = token.RequestID
+	}
+
+	sess, err := session.NewSession()
+	if err != nil {
+		return err
+	}
+	reporter := t.NewCodePipelineReporter(sess, job.ID)
+
+	pollInterval, err := strconv.Atoi(l.Env("POLL_INTERVAL", "30"))
+	if err != nil {
+		return err
+	}
+
+	travisJob := t.NewJob(
+		l.Env("BRANCH", ""),
+		l.Env("REPO_OWNER", ""),
+		l.Env("REPO_NAME", ""),
+		l.Env("TRAVIS_TOKEN", ""),
+		l.Env("TRAVIS_TLD", ""),
+		pollInterval,
+		reporter,
+		requestID,
+	)
+
+	travisJob.Execute(ctx)
+	return nil
+}
+
+func main() {
+	lambda.Start(handleCodePipelineJob)
+}
@@ -2,17 +2,16 @@ package lib
 
 import (
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 	"os"
 )
 
-// Env looks env value for passed in key, logging and failing if not set
-func Env(name string) string {
+// Env looks up the env value for name, returning fallback if it's not set.
+func Env(name string, fallback string) string {
 	zerolog.TimeFieldFormat = ""
 
 	v, ok := os.LookupEnv(name)
 	if !ok {
-		log.Fatal().Str("Name", name).Msg("Environment variable is not set.")
+		return fallback
 	}
 	return v
 }
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
 	l "github.com/ragurney/travis-job/internal/lib"
 	t "github.com/ragurney/travis-job/pkg/travis"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"strconv"
 )
 
 func main() {
@@ -21,16 +27,78 @@ func main() {
 		log.Fatal().Msg("Failed to parse POLL_INTERVAL")
 	}
 
-	log.Debug().Msg("Starting Travis job...")
-	t.NewJob(
+	job := t.NewJob(
 		branch,
 		repoOwner,
 		repoName,
 		travisToken,
 		travisTLD,
 		pollInterval,
-	).Execute()
+		t.StdoutReporter{},
+		"",
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Opt-in: if WEBHOOK_LISTEN_ADDR is set, wait for Travis to push a build
+	// notification instead of polling the API, which avoids rate-limit issues
+	// on long-running builds. The receiver reuses the Job's resolved base URL
+	// rather than the raw TLD, so it keys off the same .com/.org/Enterprise
+	// host the Job ends up talking to.
+	if webhookAddr := l.Env("WEBHOOK_LISTEN_ADDR", ""); webhookAddr != "" {
+		base, err := job.BaseURL(ctx)
+		if err != nil {
+			log.Fatal().Msgf("JOB - TRAVIS: %s", err.Error())
+		}
 
-	// Wait for result from job
-	select {}
+		receiver := t.NewWebhookReceiver(base)
+		job.UseWebhookReceiver(receiver)
+		go func() {
+			if err := http.ListenAndServe(webhookAddr, receiver); err != nil {
+				log.Fatal().Msgf("JOB - TRAVIS: webhook listener failed: %s", err.Error())
+			}
+		}()
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigCh
+		log.Info().Msgf("JOB - TRAVIS: Received %s, canceling outstanding build...", sig)
+		if err := job.Cancel(context.Background()); err != nil {
+			log.Error().Msgf("JOB - TRAVIS: Failed to cancel build: %s", err.Error())
+		}
+		cancel()
+	}()
+
+	// Log every stage transition Execute observes, and tail each Travis job's
+	// log to stdout as soon as it starts, rather than just waiting silently
+	// for the final result.
+	tailing := map[string]struct{}{}
+	go func() {
+		for ev := range job.Events() {
+			if ev.JobID == "" {
+				log.Debug().Msgf("JOB - TRAVIS: build %s -> %s", ev.BuildID, ev.Stage)
+				continue
+			}
+
+			log.Debug().Msgf("JOB - TRAVIS: job %s (build %s) -> %s", ev.JobID, ev.BuildID, ev.Stage)
+			if ev.Stage != t.StageStarted {
+				continue
+			}
+			if _, ok := tailing[ev.JobID]; ok {
+				continue
+			}
+			tailing[ev.JobID] = struct{}{}
+			go func(jobID string) {
+				if err := job.TailLog(ctx, jobID, os.Stdout); err != nil {
+					log.Error().Msgf("JOB - TRAVIS: tailing log for job %s: %s", jobID, err.Error())
+				}
+			}(ev.JobID)
+		}
+	}()
+
+	log.Debug().Msg("Starting Travis job...")
+	job.Execute(ctx)
 }
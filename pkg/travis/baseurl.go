@@ -0,0 +1,97 @@
+package travis
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	comBaseURL = "https://api.travis-ci.com"
+	orgBaseURL = "https://api.travis-ci.org"
+)
+
+// BaseURL resolves and returns the Travis API base URL this Job uses for its
+// requests. It's exported so a caller wiring up a WebhookReceiver (see
+// UseWebhookReceiver) can reuse the same .com/.org/Enterprise resolution
+// instead of re-deriving it from the raw TLD.
+func (j *Job) BaseURL(ctx context.Context) (string, error) {
+	return j.baseURL(ctx)
+}
+
+// baseURL resolves the Travis API base URL to use for this Job's requests,
+// caching the result. travisTLD may be:
+//   - a full URL, for Travis Enterprise installs (e.g. "https://travis.example.com/api")
+//   - "com" or "org", to pin .com or .org explicitly
+//   - empty, to auto-detect via detectBaseURL
+//
+// baseURLMu guards resolvedBaseURL since it's read and written from both the
+// Execute call path (triggerBuild, getBuildStatus) and the SIGINT-handler
+// goroutine main.go spawns for Cancel/Restart (buildAction), the same way
+// buildIDMu guards currentBuildID.
+func (j *Job) baseURL(ctx context.Context) (string, error) {
+	j.baseURLMu.Lock()
+	defer j.baseURLMu.Unlock()
+
+	if j.resolvedBaseURL != "" {
+		return j.resolvedBaseURL, nil
+	}
+
+	switch {
+	case strings.Contains(j.travisTLD, "://"):
+		j.resolvedBaseURL = strings.TrimSuffix(j.travisTLD, "/")
+	case j.travisTLD == "com":
+		j.resolvedBaseURL = comBaseURL
+	case j.travisTLD == "org":
+		j.resolvedBaseURL = orgBaseURL
+	default:
+		detected, err := j.detectBaseURL(ctx)
+		if err != nil {
+			return "", err
+		}
+		j.resolvedBaseURL = detected
+	}
+
+	return j.resolvedBaseURL, nil
+}
+
+// detectBaseURL probes GET /repo/{slug} on .com first, falling back to .org
+// when that returns a 404, per Travis's .com/.org migration:
+// https://docs.travis-ci.com/user/migrate/
+func (j *Job) detectBaseURL(ctx context.Context) (string, error) {
+	slug := fmt.Sprintf("%s%%2F%s", j.repoOwner, j.repoName)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/repo/%s", comBaseURL, slug), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Travis-API-Version", "3")
+	req.Header.Set("Authorization", fmt.Sprintf("token %s", j.travisToken))
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return orgBaseURL, nil
+	}
+	return comBaseURL, nil
+}
+
+// webURL derives the browser-facing travis-ci.{com,org} URL (as opposed to
+// the api.travis-ci.* base URL) from the resolved base URL, for human-readable
+// log messages.
+func (j *Job) webURL(ctx context.Context) (string, error) {
+	base, err := j.baseURL(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.HasPrefix(base, "https://api.travis-ci.") {
+		return "https://travis-ci." + strings.TrimPrefix(base, "https://api.travis-ci."), nil
+	}
+	return strings.TrimSuffix(base, "/api"), nil
+}
@@ -0,0 +1,108 @@
+package travis
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// TestBaseURLResolvesKnownTLDs covers the three travisTLD forms that resolve
+// without a network round-trip: "com", "org", and a full Enterprise URL.
+func TestBaseURLResolvesKnownTLDs(t *testing.T) {
+	tests := []struct {
+		name string
+		tld  string
+		want string
+	}{
+		{"com", "com", comBaseURL},
+		{"org", "org", orgBaseURL},
+		{"enterprise", "https://travis.example.com/api/", "https://travis.example.com/api"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := NewJob("main", "owner", "repo", "token", tt.tld, 30, StdoutReporter{}, "")
+
+			got, err := j.BaseURL(context.Background())
+			if err != nil {
+				t.Fatalf("BaseURL returned error: %s", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBaseURLCachesResolvedValue asserts baseURL only resolves once, reusing
+// j.resolvedBaseURL on subsequent calls.
+func TestBaseURLCachesResolvedValue(t *testing.T) {
+	j := NewJob("main", "owner", "repo", "token", "com", 30, StdoutReporter{}, "")
+
+	first, err := j.BaseURL(context.Background())
+	if err != nil {
+		t.Fatalf("BaseURL returned error: %s", err)
+	}
+
+	j.resolvedBaseURL = "https://cached.example.com"
+
+	second, err := j.BaseURL(context.Background())
+	if err != nil {
+		t.Fatalf("BaseURL returned error: %s", err)
+	}
+	if first == second {
+		t.Fatalf("got %q both times, want the second call to return the cached override", second)
+	}
+	if second != "https://cached.example.com" {
+		t.Fatalf("got %q, want the cached value to be returned", second)
+	}
+}
+
+// TestBaseURLConcurrentAccess reproduces the data race baseURL had on
+// resolvedBaseURL between the Execute call path (triggerBuild, via baseURL)
+// and the SIGINT-handler goroutine main.go spawns for Cancel (buildAction,
+// via baseURL): both read and wrote the field with no lock. Run with -race.
+func TestBaseURLConcurrentAccess(t *testing.T) {
+	j := NewJob("main", "owner", "repo", "token", "com", 30, StdoutReporter{}, "")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := j.baseURL(context.Background()); err != nil {
+				t.Errorf("baseURL returned error: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestWebURL asserts webURL derives the browser-facing travis-ci.{com,org}
+// host from the resolved api.travis-ci.* base URL, and falls back to
+// trimming "/api" for Enterprise installs that don't match that pattern.
+func TestWebURL(t *testing.T) {
+	tests := []struct {
+		name string
+		tld  string
+		want string
+	}{
+		{"com", "com", "https://travis-ci.com"},
+		{"org", "org", "https://travis-ci.org"},
+		{"enterprise", "https://travis.example.com/api", "https://travis.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := NewJob("main", "owner", "repo", "token", tt.tld, 30, StdoutReporter{}, "")
+
+			got, err := j.webURL(context.Background())
+			if err != nil {
+				t.Fatalf("webURL returned error: %s", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package travis
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/codepipeline"
+	"github.com/aws/aws-sdk-go/service/codepipeline/codepipelineiface"
+)
+
+// ContinuationToken is the state CodePipelineReporter persists between Lambda
+// invocations via CodePipeline's continuation token mechanism. It carries the
+// Travis request ID so the next invocation of Job.Execute can skip
+// triggerBuild and resume checking on the same build.
+type ContinuationToken struct {
+	RequestID string `json:"requestId"`
+}
+
+// CodePipelineReporter reports build results back to an AWS CodePipeline job,
+// allowing Job.Execute to run as a short-lived Lambda handler: it returns
+// immediately after triggering the build (via ReportInProgress's continuation
+// token) instead of blocking for the life of the build, and resumes on
+// CodePipeline's next invocation.
+type CodePipelineReporter struct {
+	client codepipelineiface.CodePipelineAPI
+	jobID  string
+}
+
+// NewCodePipelineReporter creates a CodePipelineReporter for the CodePipeline
+// job identified by jobID, using sess to talk to the CodePipeline API.
+func NewCodePipelineReporter(sess *session.Session, jobID string) *CodePipelineReporter {
+	return &CodePipelineReporter{
+		client: codepipeline.New(sess),
+		jobID:  jobID,
+	}
+}
+
+// ReportSuccess tells CodePipeline the job succeeded.
+func (c *CodePipelineReporter) ReportSuccess(b BuildResult) error {
+	_, err := c.client.PutJobSuccessResult(&codepipeline.PutJobSuccessResultInput{
+		JobId: aws.String(c.jobID),
+	})
+	return err
+}
+
+// ReportFailure tells CodePipeline the job failed.
+func (c *CodePipelineReporter) ReportFailure(b BuildResult, buildErr error) error {
+	msg := b.State
+	if buildErr != nil {
+		msg = buildErr.Error()
+	}
+
+	_, err := c.client.PutJobFailureResult(&codepipeline.PutJobFailureResultInput{
+		JobId: aws.String(c.jobID),
+		FailureDetails: &codepipeline.FailureDetails{
+			Type:    aws.String(codepipeline.FailureTypeJobFailed),
+			Message: aws.String(msg),
+		},
+	})
+	return err
+}
+
+// ReportInProgress tells CodePipeline the job is still running, attaching a
+// continuation token carrying b.ID so the next Lambda invocation can resume
+// checking the same Travis build.
+func (c *CodePipelineReporter) ReportInProgress(b BuildResult) error {
+	token, err := json.Marshal(ContinuationToken{RequestID: b.ID})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.PutJobSuccessResult(&codepipeline.PutJobSuccessResultInput{
+		JobId:             aws.String(c.jobID),
+		ContinuationToken: aws.String(string(token)),
+	})
+	return err
+}
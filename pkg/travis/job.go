@@ -2,26 +2,40 @@ package travis
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"io/ioutil"
 	"net/http"
-	"os"
+	"sync"
 	"time"
 )
 
 // Job a Travis action's job configuration
 type Job struct {
-	branch       string `required:"true"`
-	client       *http.Client
-	repoOwner    string `required:"true"`
-	repoName     string `required:"true"`
-	travisToken  string `required:"true"`
-	travisTLD    string `required:"true"`
-	pollInterval int    `required:"true"`
+	branch              string `required:"true"`
+	client              *http.Client
+	streamClient        *http.Client
+	repoOwner           string `required:"true"`
+	repoName            string `required:"true"`
+	travisToken         string `required:"true"`
+	travisTLD           string `required:"true"`
+	pollInterval        int    `required:"true"`
+	webhookReceiver     *WebhookReceiver
+	reporter            StatusReporter
+	continuationToken   string
+	baseURLMu           sync.Mutex
+	resolvedBaseURL     string
+	buildIDMu           sync.Mutex
+	currentBuildID      string
+	events              chan StageEvent
+	externalEventsMu    sync.Mutex
+	externalEvents      chan StageEvent
+	maxRetries          int
+	pollIntervalMu      sync.Mutex
+	currentPollInterval int
 }
 
 type triggerBuildResponse struct {
@@ -34,6 +48,9 @@ type build struct {
 	ID            json.Number `json:"id"`
 	PreviousState string      `json:"previous_state"`
 	State         string      `json:"state"`
+	Commit        struct {
+		Sha string `json:"sha"`
+	} `json:"commit"`
 }
 
 type buildStatusResponse struct {
@@ -57,68 +74,90 @@ var travisDoneTermSet = map[string]struct{}{
 	"canceled": {},
 }
 
-// NewJob initializes a Travis action's job
-func NewJob(branch string, owner string, repoName string, token string, tld string, pi int) *Job {
+// errNoBuildsFound is returned by getBuildStatus when Travis has not yet
+// materialized a build for a triggered request. It is expected immediately
+// after triggerBuild returns and is treated as in-progress, not a failure,
+// by executeContinuation.
+var errNoBuildsFound = errors.New("JOB - TRAVIS: no builds found")
+
+// NewJob initializes a Travis action's job. tld is "com"/"org" to pin a
+// specific Travis TLD, a full URL for a Travis Enterprise install (e.g.
+// "https://travis.example.com/api"), or empty to auto-detect .com vs .org
+// (see baseURL). reporter determines how build results are surfaced;
+// continuationToken, when non-empty, is the Travis request ID persisted from
+// a previous invocation (see CodePipelineReporter) and causes Execute to skip
+// triggerBuild and resume checking that build. opts configures optional
+// behavior such as WithHTTPClient and WithMaxRetries.
+func NewJob(branch string, owner string, repoName string, token string, tld string, pi int, reporter StatusReporter, continuationToken string, opts ...Option) *Job {
 	zerolog.TimeFieldFormat = ""
 
 	j := Job{
-		client:       &http.Client{Timeout: 5 * time.Second},
-		branch:       branch,
-		repoOwner:    owner,
-		repoName:     repoName,
-		travisToken:  token,
-		travisTLD:    tld,
-		pollInterval: pi,
+		client:            &http.Client{Timeout: 5 * time.Second},
+		streamClient:      &http.Client{}, // no timeout: log tailing can run for the life of a build
+		branch:            branch,
+		repoOwner:         owner,
+		repoName:          repoName,
+		travisToken:       token,
+		travisTLD:         tld,
+		pollInterval:      pi,
+		reporter:          reporter,
+		continuationToken: continuationToken,
+		maxRetries:        -1, // unset; doRequest falls back to defaultMaxRetries. WithMaxRetries(0) must mean zero retries, not "unset".
+	}
+
+	for _, opt := range opts {
+		opt(&j)
 	}
 
 	return &j
 }
 
-func (j *Job) triggerBuild() (requestID string, err error) {
-	// TODO: make travis action url configurable, e.g. .org vs .com
-	url := fmt.Sprintf("https://api.travis-ci.%s/repo/%s%%2F%s/requests", j.travisTLD, j.repoOwner, j.repoName)
-	data := []byte(fmt.Sprintf(`{"request": {"branch": %q}}`, j.branch))
+// UseWebhookReceiver switches the Job from polling the Travis API for build
+// status to waiting on a webhook notification routed through w. This avoids
+// API rate-limit issues on long-running builds.
+func (j *Job) UseWebhookReceiver(w *WebhookReceiver) {
+	j.webhookReceiver = w
+}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+func (j *Job) triggerBuild(ctx context.Context) (requestID string, err error) {
+	base, err := j.baseURL(ctx)
 	if err != nil {
 		return "", err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Travis-API-Version", "3")
-	req.Header.Set("Authorization", fmt.Sprintf("token %s", j.travisToken))
+	url := fmt.Sprintf("%s/repo/%s%%2F%s/requests", base, j.repoOwner, j.repoName)
+	data := []byte(fmt.Sprintf(`{"request": {"branch": %q}}`, j.branch))
 
-	resp, err := j.client.Do(req)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(data))
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Travis-API-Version", "3")
+	req.Header.Set("Authorization", fmt.Sprintf("token %s", j.travisToken))
 
-	body, err := ioutil.ReadAll(resp.Body)
+	_, body, err := j.doRequest(req)
 	if err != nil {
 		return "", err
 	}
 
 	res := triggerBuildResponse{}
-	err = json.Unmarshal(body, &res)
-	if err != nil {
+	if err := json.Unmarshal(body, &res); err != nil {
 		return "", err
 	}
 
 	return string(res.Request.ID), nil
 }
 
-func (j *Job) getBuildStatus(requestID string) (b build, err error) {
+func (j *Job) getBuildStatus(ctx context.Context, requestID string) (b build, err error) {
 	log.Debug().Msgf("JOB - TRAVIS: Fetching build status for request '%s'", requestID)
 
-	url := fmt.Sprintf(
-		"https://api.travis-ci.%s/repo/%s%%2F%s/request/%s",
-		j.travisTLD,
-		j.repoOwner,
-		j.repoName,
-		requestID,
-	)
+	base, err := j.baseURL(ctx)
+	if err != nil {
+		return build{}, err
+	}
+	url := fmt.Sprintf("%s/repo/%s%%2F%s/request/%s", base, j.repoOwner, j.repoName, requestID)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return build{}, errors.New("JOB - TRAVIS: Error trying to fetch build status")
 	}
@@ -126,102 +165,223 @@ func (j *Job) getBuildStatus(requestID string) (b build, err error) {
 	req.Header.Set("Travis-API-Version", "3")
 	req.Header.Set("Authorization", fmt.Sprintf("token %s", j.travisToken))
 
-	resp, err := j.client.Do(req) // TODO: check response status
-	if err != nil {
-		return build{}, err
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
+	_, body, err := j.doRequest(req)
 	if err != nil {
 		return build{}, err
 	}
 
 	res := buildStatusResponse{}
-	err = json.Unmarshal(body, &res)
-	if err != nil {
+	if err := json.Unmarshal(body, &res); err != nil {
 		return build{}, err
 	}
 
 	if len(res.Builds) > 0 {
+		j.setCurrentBuildID(string(res.Builds[0].ID))
 		return res.Builds[0], nil // Only expect one build for branch
 	}
-	return build{}, errors.New("no builds found") // TODO: maybe shouldn't be an error
+	return build{}, errNoBuildsFound
 }
 
-func (j *Job) pollForResult(requestID string) (build, error) {
-	c := make(chan build, 1)
-	sentBuildID := false
+// setCurrentBuildID records the ID of the most recently observed build. It's
+// written from the polling/webhook goroutines driving Execute and read from
+// the SIGINT-handler goroutine via getCurrentBuildID (buildAction), so both
+// are guarded by buildIDMu.
+func (j *Job) setCurrentBuildID(id string) {
+	j.buildIDMu.Lock()
+	defer j.buildIDMu.Unlock()
+	j.currentBuildID = id
+}
 
-	ticker := time.NewTicker(time.Duration(j.pollInterval) * time.Second)
-	go func() {
-		for range ticker.C {
-			log.Debug().Msg("JOB - TRAVIS: Polling for build result...")
-			if b, err := j.getBuildStatus(requestID); err != nil {
-				log.Error().Msgf("JOB - TRAVIS: %s", err.Error())
-			} else {
-				if !sentBuildID {
-					log.Debug().Msgf(
-						"JOB - TRAVIS: Build started: https://travis-ci.%s/%s/%s/builds/%s",
-						j.travisTLD,
-						j.repoOwner,
-						j.repoName,
-						b.ID,
-					)
-					sentBuildID = true
-				}
-				if contains(travisDoneTermSet, b.State) {
-					c <- b
-				}
+// getCurrentBuildID returns the ID of the most recently observed build, or
+// "" if none has been observed yet.
+func (j *Job) getCurrentBuildID() string {
+	j.buildIDMu.Lock()
+	defer j.buildIDMu.Unlock()
+	return j.currentBuildID
+}
+
+// pollForResult kicks off streamStages and drains its internal event pipe
+// until a build-level terminal stage arrives, teeing every event it reads
+// onto the externally-visible Events() channel first (see publishExternal),
+// so a caller can watch per-stage transitions without racing pollForResult
+// for the same items on the same channel. The 40-minute deadline is set once
+// up front via ctx rather than re-armed on every loop iteration, so a build
+// that keeps producing stage transitions (e.g. one job after another
+// starting) doesn't push the hard timeout back indefinitely.
+func (j *Job) pollForResult(ctx context.Context, requestID string) (build, error) {
+	defer j.closeExternalEvents()
+
+	if j.webhookReceiver != nil {
+		return j.waitForWebhook(ctx, requestID)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 40*time.Minute) // TODO: make this configurable.
+	defer cancel()
+
+	j.events = make(chan StageEvent, 16)
+	go j.streamStages(ctx, requestID)
+
+	for {
+		select {
+		case ev, ok := <-j.events:
+			if !ok {
+				return build{}, errors.New("JOB - TRAVIS: stage events ended before a terminal build state was seen")
+			}
+			j.publishExternal(ev)
+			if ev.JobID == "" && contains(travisDoneTermSet, ev.Stage) {
+				return ev.Build, nil
+			}
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return build{}, errors.New("timed out waiting for build result")
 			}
+			return build{}, ctx.Err()
+		}
+	}
+}
+
+// waitForWebhook registers the Job's branch and commit with its
+// WebhookReceiver and blocks until a matching build notification arrives,
+// ctx is canceled, or the 40-minute window expires. Travis's webhook payload
+// identifies a build by branch/commit rather than the v3 request ID, so the
+// commit is resolved via getBuildStatus before registering.
+func (j *Job) waitForWebhook(ctx context.Context, requestID string) (build, error) {
+	log.Debug().Msgf("JOB - TRAVIS: Waiting for webhook notification for request '%s'", requestID)
+
+	commit := ""
+	if b, err := j.getBuildStatus(ctx, requestID); err != nil {
+		if !errors.Is(err, errNoBuildsFound) {
+			log.Error().Msgf("JOB - TRAVIS: %s", err.Error())
 		}
-	}()
+	} else {
+		commit = b.Commit.Sha
+	}
+
+	c := j.webhookReceiver.register(j.branch, commit)
 
 	select {
 	case b := <-c:
-		ticker.Stop()
 		return b, nil
+	case <-ctx.Done():
+		return build{}, ctx.Err()
 	case <-time.After(40 * time.Minute): // TODO: make this configurable.
-		ticker.Stop()
 		return build{}, errors.New("timed out waiting for build result")
 	}
 }
 
-func (j *Job) reportSuccess(buildID string) {
-	log.Debug().Msgf("JOB - TRAVIS: Reporting success for build '%s'.", buildID)
+// report sends b's terminal state to j.reporter as a success or failure.
+func (j *Job) report(b build) {
+	result := BuildResult{ID: string(b.ID), PreviousState: b.PreviousState, State: b.State}
+
+	if contains(travisSuccessTermSet, b.State) {
+		if err := j.reporter.ReportSuccess(result); err != nil {
+			log.Error().Msgf("JOB - TRAVIS: %s", err.Error())
+		}
+		return
+	}
 
-	// report success
-	os.Exit(0)
+	if err := j.reporter.ReportFailure(result, fmt.Errorf("build %s", b.State)); err != nil {
+		log.Error().Msgf("JOB - TRAVIS: %s", err.Error())
+	}
 }
 
-func (j *Job) reportFailure(buildID string) {
-	log.Debug().Msgf("JOB - TRAVIS: Reporting failure for build '%s'.", buildID)
+// executeContinuation performs a single status check for requestID and
+// reports the outcome, used when j.reporter is a CodePipelineReporter: it
+// returns immediately rather than blocking until the build finishes, relying
+// on CodePipeline to re-invoke Execute with a continuation token once the
+// next check is due. Errors go through j.reporter rather than log.Fatal, so
+// CodePipeline sees a failed job instead of a Lambda invocation that just
+// hangs until it times out; errNoBuildsFound is reported as in-progress
+// rather than a failure, since Travis hasn't materialized the build yet
+// immediately after triggerBuild returns.
+func (j *Job) executeContinuation(ctx context.Context, requestID string) {
+	b, err := j.getBuildStatus(ctx, requestID)
+	if err != nil {
+		if errors.Is(err, errNoBuildsFound) {
+			if err := j.reporter.ReportInProgress(BuildResult{ID: requestID}); err != nil {
+				log.Error().Msgf("JOB - TRAVIS: %s", err.Error())
+			}
+			return
+		}
+		if err := j.reporter.ReportFailure(BuildResult{ID: requestID}, err); err != nil {
+			log.Error().Msgf("JOB - TRAVIS: %s", err.Error())
+		}
+		return
+	}
 
-	// report failure
-	os.Exit(1)
-}
+	if contains(travisDoneTermSet, b.State) {
+		j.report(b)
+		return
+	}
 
-func (j *Job) reportStatus(buildID string, status string) {
-	if contains(travisSuccessTermSet, status) {
-		j.reportSuccess(buildID)
+	result := BuildResult{ID: requestID, PreviousState: b.PreviousState, State: b.State}
+	if err := j.reporter.ReportInProgress(result); err != nil {
+		log.Error().Msgf("JOB - TRAVIS: %s", err.Error())
 	}
-	j.reportFailure(buildID)
 }
 
-// Execute starts Travis job. If it is a new job (no continuation token present), it first submits a new
-// travis build, then reports the build id to CodePipeline. If it is a continuing job, it polls Travis for
-// build progress and reports the result back to CodePipeline once it is complete.
-func (j *Job) Execute() {
-	var err error
-
-	if requestID, err := j.triggerBuild(); err == nil {
-		if b, err := j.pollForResult(requestID); err == nil {
-			j.reportStatus(string(b.ID), b.State)
+// Execute starts the Travis job. If it is a new job (no continuation token present), it first submits a
+// new Travis build, then either blocks waiting for the result (StdoutReporter, WebhookReporter) or, when
+// running behind a CodePipelineReporter, reports the build in progress and returns so it can be invoked
+// again as a short-lived Lambda handler. If it is a continuing job (continuationToken set), it skips
+// triggerBuild and resumes checking the existing build. Canceling ctx aborts the wait for a result; it
+// does not by itself cancel the Travis build (see Cancel).
+func (j *Job) Execute(ctx context.Context) {
+	requestID := j.continuationToken
+	if requestID == "" {
+		var err error
+		requestID, err = j.triggerBuild(ctx)
+		if err != nil {
+			log.Fatal().Msgf("JOB - TRAVIS: %s", err.Error())
 		}
 	}
+
+	if _, lambda := j.reporter.(*CodePipelineReporter); lambda {
+		j.executeContinuation(ctx, requestID)
+		return
+	}
+
+	b, err := j.pollForResult(ctx, requestID)
 	if err != nil {
 		log.Fatal().Msgf("JOB - TRAVIS: %s", err.Error())
 	}
+	j.report(b)
+}
+
+// Cancel cancels the Travis build this Job is currently waiting on, via
+// POST /build/{id}/cancel. It is a no-op error if no build has been observed
+// yet (triggerBuild has not returned, or getBuildStatus hasn't run).
+func (j *Job) Cancel(ctx context.Context) error {
+	return j.buildAction(ctx, "cancel")
+}
+
+// Restart restarts the Travis build this Job is currently waiting on, via
+// POST /build/{id}/restart.
+func (j *Job) Restart(ctx context.Context) error {
+	return j.buildAction(ctx, "restart")
+}
+
+func (j *Job) buildAction(ctx context.Context, action string) error {
+	buildID := j.getCurrentBuildID()
+	if buildID == "" {
+		return fmt.Errorf("JOB - TRAVIS: no build to %s", action)
+	}
+
+	base, err := j.baseURL(ctx)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/build/%s/%s", base, buildID, action)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Travis-API-Version", "3")
+	req.Header.Set("Authorization", fmt.Sprintf("token %s", j.travisToken))
+
+	_, _, err = j.doRequest(req)
+	return err
 }
 
 func contains(set map[string]struct{}, item string) bool {
@@ -0,0 +1,47 @@
+package travis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// TailLog streams a job's log to w as it's produced, using Travis's
+// "text/plain" streaming representation of GET /job/{id}/log. It blocks
+// until the log is complete or ctx is canceled, so it's meant to be run in
+// its own goroutine alongside Execute/pollForResult.
+func (j *Job) TailLog(ctx context.Context, jobID string, w io.Writer) error {
+	base, err := j.baseURL(ctx)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/job/%s/log", base, jobID)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Travis-API-Version", "3")
+	req.Header.Set("Accept", "text/plain")
+	req.Header.Set("Authorization", fmt.Sprintf("token %s", j.travisToken))
+
+	resp, err := j.streamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("JOB - TRAVIS: got status %d fetching log for job '%s'", resp.StatusCode, jobID)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if _, err := fmt.Fprintln(w, scanner.Text()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
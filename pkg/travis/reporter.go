@@ -0,0 +1,114 @@
+package travis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// BuildResult is the terminal (or in-progress) state of a triggered Travis
+// build, passed to a StatusReporter.
+type BuildResult struct {
+	ID            string
+	PreviousState string
+	State         string
+}
+
+// StatusReporter surfaces the outcome of a triggered Travis build to whatever
+// system is waiting on it. Job.Execute calls ReportSuccess/ReportFailure once
+// the build reaches a terminal state. ReportInProgress is only called when
+// Execute runs behind a CodePipelineReporter and the build has not finished;
+// otherwise Execute blocks until the terminal state instead.
+type StatusReporter interface {
+	ReportSuccess(BuildResult) error
+	ReportFailure(BuildResult, error) error
+	ReportInProgress(BuildResult) error
+}
+
+// StdoutReporter preserves travis-job's original behavior: log the result and
+// exit the process with a status code matching success/failure.
+type StdoutReporter struct{}
+
+// ReportSuccess logs the successful build and exits 0.
+func (StdoutReporter) ReportSuccess(b BuildResult) error {
+	log.Debug().Msgf("JOB - TRAVIS: Reporting success for build '%s'.", b.ID)
+	os.Exit(0)
+	return nil
+}
+
+// ReportFailure logs the failed build and exits 1.
+func (StdoutReporter) ReportFailure(b BuildResult, err error) error {
+	log.Debug().Msgf("JOB - TRAVIS: Reporting failure for build '%s'.", b.ID)
+	os.Exit(1)
+	return nil
+}
+
+// ReportInProgress logs the build's current state and continues waiting.
+func (StdoutReporter) ReportInProgress(b BuildResult) error {
+	log.Debug().Msgf("JOB - TRAVIS: Build '%s' still in progress (%s).", b.ID, b.State)
+	return nil
+}
+
+type webhookReportPayload struct {
+	Status string      `json:"status"`
+	Build  BuildResult `json:"build"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// WebhookReporter POSTs the build result as JSON to a configured URL, for
+// callers who want to be notified out-of-band rather than relying on the
+// exit code of a long-running process.
+type WebhookReporter struct {
+	URL    string
+	client *http.Client
+}
+
+// NewWebhookReporter creates a WebhookReporter that posts to url.
+func NewWebhookReporter(url string) *WebhookReporter {
+	return &WebhookReporter{
+		URL:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// ReportSuccess posts a "success" status payload.
+func (w *WebhookReporter) ReportSuccess(b BuildResult) error {
+	return w.post(webhookReportPayload{Status: "success", Build: b})
+}
+
+// ReportFailure posts a "failure" status payload, including err's message.
+func (w *WebhookReporter) ReportFailure(b BuildResult, err error) error {
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	return w.post(webhookReportPayload{Status: "failure", Build: b, Error: msg})
+}
+
+// ReportInProgress posts an "in_progress" status payload.
+func (w *WebhookReporter) ReportInProgress(b BuildResult) error {
+	return w.post(webhookReportPayload{Status: "in_progress", Build: b})
+}
+
+func (w *WebhookReporter) post(p webhookReportPayload) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.client.Post(w.URL, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("JOB - TRAVIS: webhook reporter got status %d from %s", resp.StatusCode, w.URL)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package travis
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultMaxRetries is used when a Job is created without WithMaxRetries.
+const defaultMaxRetries = 5
+
+// TravisError is Travis's {"@type": "error", ...} error envelope, returned by
+// doRequest for non-2xx responses.
+type TravisError struct {
+	Type    string `json:"error_type"`
+	Message string `json:"error_message"`
+}
+
+func (e *TravisError) Error() string {
+	return fmt.Sprintf("JOB - TRAVIS: %s: %s", e.Type, e.Message)
+}
+
+// Option configures optional behavior on a Job created via NewJob.
+type Option func(*Job)
+
+// WithHTTPClient overrides the http.Client used for (non-streaming) Travis
+// API requests.
+func WithHTTPClient(c *http.Client) Option {
+	return func(j *Job) { j.client = c }
+}
+
+// WithMaxRetries sets how many times doRequest retries a retryable request
+// (5xx/429 responses to idempotent GETs) before giving up. Defaults to
+// defaultMaxRetries.
+func WithMaxRetries(n int) Option {
+	return func(j *Job) { j.maxRetries = n }
+}
+
+// doRequest executes req, decoding Travis's error envelope into a
+// *TravisError on non-2xx responses. GET requests are retried with
+// exponential backoff and jitter on 5xx/429 responses, honoring a
+// Retry-After header when Travis sends one. 429 responses also back off the
+// Job's poll interval; any success resets it.
+func (j *Job) doRequest(req *http.Request) (*http.Response, []byte, error) {
+	maxRetries := j.maxRetries
+	if maxRetries < 0 {
+		maxRetries = defaultMaxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := j.client.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			j.resetPollInterval()
+			return resp, body, nil
+		}
+
+		travisErr := &TravisError{}
+		if jsonErr := json.Unmarshal(body, travisErr); jsonErr != nil || travisErr.Type == "" {
+			travisErr = &TravisError{Type: resp.Status, Message: string(body)}
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			j.backOffPollInterval()
+		}
+
+		retryable := req.Method == http.MethodGet &&
+			(resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500)
+		if !retryable || attempt >= maxRetries {
+			return resp, body, travisErr
+		}
+
+		wait := retryBackoff(attempt, resp.Header.Get("Retry-After"))
+		log.Debug().Msgf(
+			"JOB - TRAVIS: retrying after %s (attempt %d/%d): %s",
+			wait, attempt+1, maxRetries, travisErr.Error(),
+		)
+
+		select {
+		case <-req.Context().Done():
+			return nil, nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryBackoff computes how long to wait before the next retry attempt,
+// honoring a Retry-After header (seconds or HTTP-date) when present and
+// falling back to exponential backoff with jitter otherwise.
+func retryBackoff(attempt int, retryAfter string) time.Duration {
+	if retryAfter != "" {
+		if secs, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(secs) * time.Second
+		}
+		if t, err := http.ParseTime(retryAfter); err == nil {
+			if d := time.Until(t); d > 0 {
+				return d
+			}
+		}
+	}
+
+	base := time.Duration(1<<uint(attempt)) * time.Second
+	jitter := time.Duration(rand.Int63n(int64(base/2) + 1))
+	return base + jitter
+}
+
+// backOffPollInterval doubles the Job's poll interval, up to 8x its
+// configured base, in response to a 429 from Travis.
+func (j *Job) backOffPollInterval() {
+	j.pollIntervalMu.Lock()
+	defer j.pollIntervalMu.Unlock()
+
+	if j.currentPollInterval == 0 {
+		j.currentPollInterval = j.pollInterval
+	}
+	j.currentPollInterval *= 2
+	if max := j.pollInterval * 8; j.currentPollInterval > max {
+		j.currentPollInterval = max
+	}
+}
+
+// resetPollInterval restores the Job's poll interval to its configured base.
+func (j *Job) resetPollInterval() {
+	j.pollIntervalMu.Lock()
+	defer j.pollIntervalMu.Unlock()
+	j.currentPollInterval = j.pollInterval
+}
+
+// pollIntervalDuration returns how long streamStages should currently wait
+// between polls.
+func (j *Job) pollIntervalDuration() time.Duration {
+	j.pollIntervalMu.Lock()
+	defer j.pollIntervalMu.Unlock()
+
+	if j.currentPollInterval == 0 {
+		return time.Duration(j.pollInterval) * time.Second
+	}
+	return time.Duration(j.currentPollInterval) * time.Second
+}
@@ -0,0 +1,168 @@
+package travis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestRetryBackoffHonorsRetryAfterSeconds asserts a numeric Retry-After header
+// is used verbatim rather than falling through to exponential backoff.
+func TestRetryBackoffHonorsRetryAfterSeconds(t *testing.T) {
+	got := retryBackoff(0, "2")
+	if got != 2*time.Second {
+		t.Fatalf("got %s, want 2s", got)
+	}
+}
+
+// TestRetryBackoffHonorsRetryAfterDate asserts an HTTP-date Retry-After
+// header is converted to a duration relative to now.
+func TestRetryBackoffHonorsRetryAfterDate(t *testing.T) {
+	future := time.Now().Add(3 * time.Second)
+	got := retryBackoff(0, future.UTC().Format(http.TimeFormat))
+	if got <= 0 || got > 3*time.Second {
+		t.Fatalf("got %s, want a positive duration at most 3s", got)
+	}
+}
+
+// TestRetryBackoffExponential asserts backoff grows with attempt and always
+// includes the base delay, even without a Retry-After header.
+func TestRetryBackoffExponential(t *testing.T) {
+	for attempt := 0; attempt < 4; attempt++ {
+		base := time.Duration(1<<uint(attempt)) * time.Second
+		got := retryBackoff(attempt, "")
+		if got < base {
+			t.Fatalf("attempt %d: got %s, want at least %s", attempt, got, base)
+		}
+	}
+}
+
+// TestDoRequestRetries503ThenSucceeds asserts doRequest retries a GET on 5xx
+// responses, honoring Retry-After to avoid a slow real test, and returns the
+// eventual success.
+func TestDoRequestRetries503ThenSucceeds(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			rw.Header().Set("Retry-After", "0")
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	j := NewJob("main", "owner", "repo", "token", server.URL, 30, StdoutReporter{}, "", WithMaxRetries(3))
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("building request: %s", err)
+	}
+
+	resp, body, err := j.doRequest(req)
+	if err != nil {
+		t.Fatalf("doRequest returned error: %s", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want 200", resp.StatusCode)
+	}
+	if string(body) != `{"ok":true}` {
+		t.Fatalf("got body %q, want the success body", body)
+	}
+	if attempts != 3 {
+		t.Fatalf("got %d attempts, want 3", attempts)
+	}
+}
+
+// TestDoRequestGivesUpAfterMaxRetries asserts doRequest stops retrying once
+// maxRetries is exhausted and surfaces the last response as a *TravisError.
+func TestDoRequestGivesUpAfterMaxRetries(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		attempts++
+		rw.Header().Set("Retry-After", "0")
+		rw.WriteHeader(http.StatusTooManyRequests)
+		_, _ = rw.Write([]byte(`{"error_type":"rate_limit_exceeded","error_message":"too fast"}`))
+	}))
+	defer server.Close()
+
+	j := NewJob("main", "owner", "repo", "token", server.URL, 30, StdoutReporter{}, "", WithMaxRetries(2))
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("building request: %s", err)
+	}
+
+	_, _, err = j.doRequest(req)
+	if err == nil {
+		t.Fatal("expected doRequest to return an error after exhausting retries")
+	}
+	travisErr, ok := err.(*TravisError)
+	if !ok {
+		t.Fatalf("got error of type %T, want *TravisError", err)
+	}
+	if travisErr.Type != "rate_limit_exceeded" {
+		t.Fatalf("got error type %q, want rate_limit_exceeded", travisErr.Type)
+	}
+	if attempts != 3 {
+		t.Fatalf("got %d attempts, want 3 (initial + 2 retries)", attempts)
+	}
+}
+
+// TestDoRequestWithMaxRetriesZeroDoesNotRetry asserts WithMaxRetries(0) is
+// honored as "no retries" rather than being treated as unset and falling
+// back to defaultMaxRetries.
+func TestDoRequestWithMaxRetriesZeroDoesNotRetry(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		attempts++
+		rw.Header().Set("Retry-After", "0")
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	j := NewJob("main", "owner", "repo", "token", server.URL, 30, StdoutReporter{}, "", WithMaxRetries(0))
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("building request: %s", err)
+	}
+
+	_, _, err = j.doRequest(req)
+	if err == nil {
+		t.Fatal("expected doRequest to return an error")
+	}
+	if attempts != 1 {
+		t.Fatalf("got %d attempts, want 1 (no retries with WithMaxRetries(0))", attempts)
+	}
+}
+
+// TestDoRequestDoesNotRetryNonGET asserts POST/PUT/etc. are never retried,
+// even on a 5xx, since they may not be idempotent.
+func TestDoRequestDoesNotRetryNonGET(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		attempts++
+		rw.WriteHeader(http.StatusInternalServerError)
+		_, _ = rw.Write([]byte(`{"error_type":"server_error","error_message":"boom"}`))
+	}))
+	defer server.Close()
+
+	j := NewJob("main", "owner", "repo", "token", server.URL, 30, StdoutReporter{}, "")
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+	if err != nil {
+		t.Fatalf("building request: %s", err)
+	}
+
+	_, _, err = j.doRequest(req)
+	if err == nil {
+		t.Fatal("expected doRequest to return an error")
+	}
+	if attempts != 1 {
+		t.Fatalf("got %d attempts, want 1 (no retries for POST)", attempts)
+	}
+}
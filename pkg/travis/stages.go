@@ -0,0 +1,214 @@
+package travis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Travis job lifecycle stages leading up to a terminal state (one of the keys
+// in travisDoneTermSet). See https://docs.travis-ci.com/user/triggering-builds/
+const (
+	StageCreated  = "created"
+	StageQueued   = "queued"
+	StageReceived = "received"
+	StageStarted  = "started"
+)
+
+// StageEvent describes a single stage transition observed while polling a
+// build: either a build-level transition (JobID empty) or a per-job one.
+type StageEvent struct {
+	BuildID string
+	JobID   string // empty for a build-level transition
+	Stage   string
+	Build   build
+}
+
+type travisJob struct {
+	ID    json.Number `json:"id"`
+	State string      `json:"state"`
+}
+
+type buildDetail struct {
+	ID    json.Number `json:"id"`
+	State string      `json:"state"`
+	Jobs  []travisJob `json:"jobs"`
+}
+
+// getBuildDetail fetches a build's per-job breakdown via GET
+// /build/{id}?include=build.jobs.
+func (j *Job) getBuildDetail(ctx context.Context, buildID string) (buildDetail, error) {
+	base, err := j.baseURL(ctx)
+	if err != nil {
+		return buildDetail{}, err
+	}
+	url := fmt.Sprintf("%s/build/%s?include=build.jobs", base, buildID)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return buildDetail{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Travis-API-Version", "3")
+	req.Header.Set("Authorization", fmt.Sprintf("token %s", j.travisToken))
+
+	_, body, err := j.doRequest(req)
+	if err != nil {
+		return buildDetail{}, err
+	}
+
+	res := buildDetail{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return buildDetail{}, err
+	}
+	return res, nil
+}
+
+// Events returns a channel of StageEvent values describing each stage
+// transition of the build (and its jobs), following the chunked-stage model
+// Drone/Woodpecker's build controller uses. pollForResult tees every event
+// it reads from streamStages onto this channel before filtering it for the
+// build-level terminal state it's waiting on, so callers (e.g. main.go) can
+// watch per-stage transitions, including per-job ones pollForResult itself
+// discards, without racing pollForResult to drain the same items. The
+// channel is closed once pollForResult returns.
+func (j *Job) Events() <-chan StageEvent {
+	j.externalEventsMu.Lock()
+	defer j.externalEventsMu.Unlock()
+
+	if j.externalEvents == nil {
+		j.externalEvents = make(chan StageEvent, 16)
+	}
+	return j.externalEvents
+}
+
+// publishExternal tees ev onto the Events() channel, if a caller has
+// requested one, without blocking pollForResult when nobody is draining it.
+// externalEventsMu guards j.externalEvents itself (e.g. against a caller
+// requesting it via Events() from another goroutine before Execute starts);
+// the channel send/close below is safe unsynchronized once the field access
+// is, since pollForResult is the only sender and closer.
+func (j *Job) publishExternal(ev StageEvent) {
+	j.externalEventsMu.Lock()
+	events := j.externalEvents
+	j.externalEventsMu.Unlock()
+
+	if events == nil {
+		return
+	}
+	select {
+	case events <- ev:
+	default:
+		log.Warn().Msg("JOB - TRAVIS: dropping stage event, Events() consumer is not keeping up")
+	}
+}
+
+// closeExternalEvents closes the Events() channel, if one was requested,
+// once pollForResult stops draining streamStages's internal event pipe.
+func (j *Job) closeExternalEvents() {
+	j.externalEventsMu.Lock()
+	events := j.externalEvents
+	j.externalEventsMu.Unlock()
+
+	if events != nil {
+		close(events)
+	}
+}
+
+// streamStages polls the build (and its jobs') status, emitting a StageEvent
+// on the internal j.events pipe (which pollForResult drains and tees onto
+// Events()) for every stage transition observed, until the build reaches
+// a terminal state or ctx is canceled. The first poll happens immediately,
+// rather than after a full pollIntervalDuration wait, so currentBuildID (and
+// therefore Cancel/Restart) is populated as soon as possible after Execute
+// starts, instead of only after the first tick of a potentially long poll
+// interval.
+func (j *Job) streamStages(ctx context.Context, requestID string) {
+	defer close(j.events)
+
+	lastBuildStage := ""
+	lastJobStage := map[string]string{}
+	sentBuildID := false
+
+	// send emits ev on j.events, reporting false instead of blocking forever
+	// if ctx is canceled before a consumer (pollForResult) reads it — e.g.
+	// more than len(j.events) transitions arrive after the consumer has
+	// already returned on its own ctx.Done() case.
+	send := func(ev StageEvent) bool {
+		select {
+		case j.events <- ev:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	// poll reports true once streamStages should stop: the build reached a
+	// terminal state, or ctx was canceled while emitting an event.
+	poll := func() (stop bool) {
+		log.Debug().Msg("JOB - TRAVIS: Polling for build result...")
+		b, err := j.getBuildStatus(ctx, requestID)
+		if err != nil {
+			log.Error().Msgf("JOB - TRAVIS: %s", err.Error())
+			return false
+		}
+
+		if !sentBuildID {
+			if webURL, err := j.webURL(ctx); err != nil {
+				log.Error().Msgf("JOB - TRAVIS: %s", err.Error())
+			} else {
+				log.Debug().Msgf(
+					"JOB - TRAVIS: Build started: %s/%s/%s/builds/%s",
+					webURL,
+					j.repoOwner,
+					j.repoName,
+					b.ID,
+				)
+			}
+			sentBuildID = true
+		}
+
+		if detail, err := j.getBuildDetail(ctx, string(b.ID)); err != nil {
+			log.Error().Msgf("JOB - TRAVIS: %s", err.Error())
+		} else {
+			for _, tj := range detail.Jobs {
+				jobID := string(tj.ID)
+				if lastJobStage[jobID] == tj.State {
+					continue
+				}
+				lastJobStage[jobID] = tj.State
+				if !send(StageEvent{BuildID: string(b.ID), JobID: jobID, Stage: tj.State, Build: b}) {
+					return true
+				}
+			}
+		}
+
+		if lastBuildStage != b.State {
+			lastBuildStage = b.State
+			if !send(StageEvent{BuildID: string(b.ID), Stage: b.State, Build: b}) {
+				return true
+			}
+		}
+
+		return contains(travisDoneTermSet, b.State)
+	}
+
+	if poll() {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(j.pollIntervalDuration()):
+			if poll() {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package travis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestStreamStagesStopsWhenContextCanceledMidSend reproduces the goroutine
+// leak streamStages had when more stage transitions arrived in a single poll
+// than j.events's buffer could hold and the consumer (pollForResult) had
+// already stopped reading on its own ctx.Done() case: the blocked
+// `j.events <- ev` send had no way to notice and never returned. Here the
+// fake build-detail response cancels ctx mid-poll and returns 100 jobs, far
+// more than the channel's 16-slot buffer, with nothing draining j.events.
+func TestStreamStagesStopsWhenContextCanceledMidSend(t *testing.T) {
+	var cancel context.CancelFunc
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "/build/"):
+			jobs := make([]travisJob, 100)
+			for i := range jobs {
+				jobs[i] = travisJob{ID: json.Number(fmt.Sprintf("%d", i)), State: "started"}
+			}
+			_ = json.NewEncoder(rw).Encode(buildDetail{ID: "1", State: "started", Jobs: jobs})
+			if f, ok := rw.(http.Flusher); ok {
+				f.Flush()
+			}
+			// Cancel once the response has had time to reach the client, so
+			// getBuildDetail succeeds and streamStages actually reaches the
+			// j.events sends this test is exercising, instead of just
+			// failing the HTTP request itself.
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				cancel()
+			}()
+		default:
+			_ = json.NewEncoder(rw).Encode(buildStatusResponse{
+				Builds: []build{{ID: "1", State: "started"}},
+			})
+		}
+	}))
+	defer server.Close()
+
+	j := NewJob("main", "owner", "repo", "token", server.URL, 30, StdoutReporter{}, "")
+	ctx, c := context.WithCancel(context.Background())
+	cancel = c
+
+	j.events = make(chan StageEvent, 16) // the pipe pollForResult normally sets up; intentionally never drained
+
+	done := make(chan struct{})
+	go func() {
+		j.streamStages(ctx, "req1")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("streamStages did not return after ctx was canceled mid-send; it's blocked forever on a channel send")
+	}
+}
@@ -0,0 +1,191 @@
+package travis
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// webhookBuild is the subset of a Travis build notification payload we care about.
+// See https://docs.travis-ci.com/user/notifications/#configuring-webhook-notifications
+type webhookBuild struct {
+	ID            json.Number `json:"id"`
+	Number        string      `json:"number"`
+	Status        string      `json:"status"`
+	StatusMessage string      `json:"status_message"`
+	Branch        string      `json:"branch"`
+	Commit        string      `json:"commit"`
+	BuildURL      string      `json:"build_url"`
+}
+
+// WebhookReceiver is an http.Handler that verifies incoming Travis-CI webhook
+// notifications and routes them to whichever Job registered for the matching
+// branch or commit. Using a receiver instead of pollForResult avoids hitting
+// Travis's API rate limits while waiting on long-running builds. Travis's
+// webhook payload identifies a build by branch/commit, not by the v3 request
+// ID triggerBuild receives, so registration and routing are keyed on those
+// instead.
+type WebhookReceiver struct {
+	baseURL string
+
+	mu      sync.Mutex
+	pubKey  *rsa.PublicKey
+	pending map[string]chan build
+}
+
+// NewWebhookReceiver creates a WebhookReceiver that verifies payloads against
+// the public key Travis publishes at {baseURL}/config. baseURL should be the
+// same resolved API base URL the Job uses (see Job.BaseURL) — building it
+// independently from a raw TLD mishandles both the default empty/auto-detect
+// case and Travis Enterprise base URLs.
+func NewWebhookReceiver(baseURL string) *WebhookReceiver {
+	return &WebhookReceiver{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		pending: make(map[string]chan build),
+	}
+}
+
+// register tells the receiver to watch for a webhook matching branch or commit,
+// returning a channel that receives the build once a matching payload arrives.
+func (w *WebhookReceiver) register(branch string, commit string) <-chan build {
+	c := make(chan build, 1)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if branch != "" {
+		w.pending[branch] = c
+	}
+	if commit != "" {
+		w.pending[commit] = c
+	}
+
+	return c
+}
+
+// ServeHTTP implements http.Handler, verifying and decoding incoming Travis webhook
+// notifications and routing them to the matching registered Job.
+func (w *WebhookReceiver) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(rw, "bad request", http.StatusBadRequest)
+		return
+	}
+	payload := req.PostFormValue("payload")
+
+	if err := w.verifySignature(req.Header.Get("Signature"), payload); err != nil {
+		log.Error().Msgf("JOB - TRAVIS: webhook signature verification failed: %s", err.Error())
+		http.Error(rw, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	var b webhookBuild
+	if err := json.Unmarshal([]byte(payload), &b); err != nil {
+		http.Error(rw, "bad payload", http.StatusBadRequest)
+		return
+	}
+
+	w.route(b)
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (w *WebhookReceiver) route(b webhookBuild) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	c, ok := w.pending[b.Commit]
+	if !ok {
+		c, ok = w.pending[b.Branch]
+	}
+	if !ok {
+		log.Debug().Msgf("JOB - TRAVIS: received webhook for unknown build '%s', ignoring", b.ID)
+		return
+	}
+
+	c <- build{ID: b.ID, State: b.Status}
+	delete(w.pending, b.Commit)
+	delete(w.pending, b.Branch)
+}
+
+func (w *WebhookReceiver) verifySignature(sigHeader string, payload string) error {
+	if sigHeader == "" {
+		return errors.New("JOB - TRAVIS: webhook request missing Signature header")
+	}
+
+	pubKey, err := w.publicKey()
+	if err != nil {
+		return err
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(sigHeader)
+	if err != nil {
+		return err
+	}
+
+	sum := sha1.Sum([]byte(payload))
+	return rsa.VerifyPKCS1v15(pubKey, crypto.SHA1, sum[:], sig)
+}
+
+type configResponse struct {
+	Config struct {
+		Notifications struct {
+			Webhook struct {
+				PublicKey string `json:"public_key"`
+			} `json:"webhook"`
+		} `json:"notifications"`
+	} `json:"config"`
+}
+
+func (w *WebhookReceiver) publicKey() (*rsa.PublicKey, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.pubKey != nil {
+		return w.pubKey, nil
+	}
+
+	url := fmt.Sprintf("%s/config", w.baseURL)
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	res := configResponse{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode([]byte(res.Config.Notifications.Webhook.PublicKey))
+	if block == nil {
+		return nil, errors.New("JOB - TRAVIS: could not decode Travis public key")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("JOB - TRAVIS: Travis public key is not RSA")
+	}
+
+	w.pubKey = pubKey
+	return pubKey, nil
+}
@@ -0,0 +1,148 @@
+package travis
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestWebhookReceiverServeHTTP drives a fake, signed Travis webhook payload
+// through ServeHTTP end-to-end and asserts it's routed to the channel
+// register returned for the matching branch.
+func TestWebhookReceiverServeHTTP(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating test key: %s", err)
+	}
+
+	w := NewWebhookReceiver("https://api.travis-ci.com")
+	w.pubKey = &key.PublicKey
+
+	c := w.register("main", "")
+
+	payload, err := json.Marshal(webhookBuild{
+		ID:     "123",
+		Number: "45",
+		Status: "passed",
+		Branch: "main",
+		Commit: "abc123",
+	})
+	if err != nil {
+		t.Fatalf("marshaling payload: %s", err)
+	}
+
+	sum := sha1.Sum(payload)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA1, sum[:])
+	if err != nil {
+		t.Fatalf("signing payload: %s", err)
+	}
+
+	form := url.Values{"payload": {string(payload)}}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Signature", base64.StdEncoding.EncodeToString(sig))
+
+	rw := httptest.NewRecorder()
+	w.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", rw.Code, http.StatusOK)
+	}
+
+	select {
+	case b := <-c:
+		if string(b.ID) != "123" || b.State != "passed" {
+			t.Fatalf("got build %+v, want ID 123 State passed", b)
+		}
+	default:
+		t.Fatal("expected registered channel to receive the routed build")
+	}
+}
+
+// TestWebhookReceiverServeHTTPBadSignature asserts an incorrectly signed
+// payload is rejected and never routed.
+func TestWebhookReceiverServeHTTPBadSignature(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating test key: %s", err)
+	}
+
+	w := NewWebhookReceiver("https://api.travis-ci.com")
+	w.pubKey = &key.PublicKey
+	c := w.register("main", "")
+
+	payload, err := json.Marshal(webhookBuild{ID: "123", Branch: "main", Status: "passed"})
+	if err != nil {
+		t.Fatalf("marshaling payload: %s", err)
+	}
+
+	form := url.Values{"payload": {string(payload)}}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Signature", base64.StdEncoding.EncodeToString([]byte("not-a-real-signature")))
+
+	rw := httptest.NewRecorder()
+	w.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusForbidden {
+		t.Fatalf("got status %d, want %d", rw.Code, http.StatusForbidden)
+	}
+
+	select {
+	case b := <-c:
+		t.Fatalf("got routed build %+v, want the rejected payload never routed", b)
+	default:
+	}
+}
+
+// TestWebhookReceiverPublicKey asserts publicKey() fetches {baseURL}/config
+// rather than re-deriving a URL from a raw TLD, which previously produced a
+// broken "https://api.travis-ci./config" host whenever the TLD was empty
+// (the default, auto-detect case) and a malformed one for an Enterprise
+// install's full API URL.
+func TestWebhookReceiverPublicKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating test key: %s", err)
+	}
+
+	keyBytes, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshaling public key: %s", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: keyBytes})
+
+	var requestedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requestedPath = req.URL.Path
+		res := configResponse{}
+		res.Config.Notifications.Webhook.PublicKey = string(keyPEM)
+		if err := json.NewEncoder(rw).Encode(res); err != nil {
+			t.Fatalf("encoding fake config response: %s", err)
+		}
+	}))
+	defer server.Close()
+
+	w := NewWebhookReceiver(server.URL)
+
+	pubKey, err := w.publicKey()
+	if err != nil {
+		t.Fatalf("publicKey() returned error: %s", err)
+	}
+	if requestedPath != "/config" {
+		t.Fatalf("got request path %q, want /config", requestedPath)
+	}
+	if pubKey.N.Cmp(key.PublicKey.N) != 0 {
+		t.Fatal("publicKey() returned a key that doesn't match the one served")
+	}
+}